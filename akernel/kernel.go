@@ -0,0 +1,233 @@
+// Package akernel provides long-running, PTY-backed interpreter processes
+// ("kernels") that keep state between successive Execute calls, so a
+// notebook cell can build on variables or declarations from an earlier
+// cell instead of starting a fresh interpreter every time.
+package akernel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// Kernel is a persistent interpreter session for one language.
+type Kernel interface {
+	// Execute sends code to the kernel and blocks until it has finished
+	// running (detected via a sentinel echoed back by the interpreter),
+	// returning what the kernel printed in response, with the terminal's
+	// echo of the sent code and bare prompts removed. The PTY-backed
+	// kernels from Spawn merge the child's stdout and stderr, so all output
+	// arrives in stdout and stderr is empty.
+	Execute(code string) (stdout, stderr string, err error)
+	// Close terminates the underlying process.
+	Close() error
+}
+
+// langSpec describes how to start a language's REPL, how to ask it to
+// print a sentinel value once a chunk of code has finished running, and
+// how to recognize its own prompt so Execute can scrub it from output.
+type langSpec struct {
+	command string
+	args    []string
+	// echo returns the statement that prints sentinel as a line of its own.
+	echo func(sentinel string) string
+	// prompt matches a leading REPL prompt on an output line, if any. This
+	// is a small heuristic rather than a claim of exact prompt parsing:
+	// good enough to strip the common case, not a full readline emulation.
+	prompt *regexp.Regexp
+}
+
+var langSpecs = map[string]langSpec{
+	"bash": {
+		command: "bash",
+		args:    []string{"--noprofile", "--norc", "-i"},
+		echo:    func(s string) string { return fmt.Sprintf("echo %s", s) },
+		prompt:  regexp.MustCompile(`^\S*?[$#]\s?`),
+	},
+	"python": {
+		command: "python3",
+		args:    []string{"-i", "-q"},
+		echo:    func(s string) string { return fmt.Sprintf("print(%q)", s) },
+		prompt:  regexp.MustCompile(`^(>>>|\.\.\.)\s?`),
+	},
+	"node": {
+		command: "node",
+		args:    []string{"-i"},
+		echo:    func(s string) string { return fmt.Sprintf("console.log(%q)", s) },
+		prompt:  regexp.MustCompile(`^(\.\.\.|>)\s?`),
+	},
+	"irb": {
+		command: "irb",
+		args:    []string{"--noecho", "--nomultiline"},
+		echo:    func(s string) string { return fmt.Sprintf("puts %q", s) },
+		prompt:  regexp.MustCompile(`^irb\([^)]*\):\d+:\d+[>*]\s?`),
+	},
+	"psql": {
+		command: "psql",
+		args:    nil,
+		echo:    func(s string) string { return fmt.Sprintf("\\echo %s", s) },
+		prompt:  regexp.MustCompile(`^\S*?[=\-][#>]\s?`),
+	},
+}
+
+// defaultTimeout bounds how long Execute waits for the sentinel before it
+// gives up and reports the kernel as hung.
+const defaultTimeout = 30 * time.Second
+
+var sentinelCounter int64
+
+func nextSentinel() string {
+	n := atomic.AddInt64(&sentinelCounter, 1)
+	return fmt.Sprintf("__CELL_DONE_%d_%d__", time.Now().UnixNano(), n)
+}
+
+// ptyKernel is a Kernel implementation backed by a pseudo-terminal, so the
+// child behaves as if attached to an interactive terminal (needed for
+// REPLs like python -i that only print their prompt/banner in that mode).
+type ptyKernel struct {
+	mu       sync.Mutex
+	language string
+	cmd      *exec.Cmd
+	ptmx     *os.File
+	buf      *syncBuffer
+	done     chan struct{}
+}
+
+// syncBuffer is a goroutine-safe append-only byte buffer fed by the
+// kernel's output-pump goroutine and drained by Execute.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// Spawn starts a new kernel for language. It returns an error for any
+// language without a registered langSpec.
+func Spawn(language string) (Kernel, error) {
+	spec, ok := langSpecs[language]
+	if !ok {
+		return nil, fmt.Errorf("no kernel available for language %q", language)
+	}
+
+	cmd := exec.Command(spec.command, spec.args...)
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("starting %s kernel: %v", language, err)
+	}
+
+	k := &ptyKernel{
+		language: language,
+		cmd:      cmd,
+		ptmx:     ptmx,
+		buf:      &syncBuffer{},
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		io.Copy(k.buf, ptmx)
+		close(k.done)
+	}()
+
+	// Some REPLs (node, irb) print a startup banner before their first
+	// prompt; drain it here so it doesn't get mixed into the first cell's
+	// own output once Execute's per-call scrubbing starts.
+	drainStartup(k.buf)
+
+	return k, nil
+}
+
+// drainStartup waits for buf to go quiet (no new bytes for a few polls) or
+// for startupDrainDeadline to pass, then discards whatever accumulated.
+const startupDrainDeadline = 2 * time.Second
+
+func drainStartup(buf *syncBuffer) {
+	deadline := time.Now().Add(startupDrainDeadline)
+	last := buf.String()
+	quiet := 0
+	for quiet < 3 && time.Now().Before(deadline) {
+		time.Sleep(30 * time.Millisecond)
+		cur := buf.String()
+		if cur == last {
+			quiet++
+		} else {
+			quiet = 0
+			last = cur
+		}
+	}
+	buf.Reset()
+}
+
+func (k *ptyKernel) Execute(code string) (string, string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	sentinel := nextSentinel()
+	k.buf.Reset()
+
+	echoStmt := langSpecs[k.language].echo(sentinel)
+	payload := code
+	if !strings.HasSuffix(payload, "\n") {
+		payload += "\n"
+	}
+	payload += echoStmt + "\n"
+
+	if _, err := io.WriteString(k.ptmx, payload); err != nil {
+		return "", "", fmt.Errorf("writing to kernel: %v", err)
+	}
+
+	// sent is everything the PTY will echo back as typed input, so
+	// scrubOutput can tell that apart from what the kernel actually printed.
+	sent := code + "\n" + echoStmt
+	prompt := langSpecs[k.language].prompt
+
+	deadline := time.Now().Add(defaultTimeout)
+	for {
+		out := k.buf.String()
+		if idx := strings.Index(out, sentinel); idx >= 0 {
+			return scrubOutput(renderPTY(out[:idx]), sent, prompt), "", nil
+		}
+		select {
+		case <-k.done:
+			return scrubOutput(renderPTY(k.buf.String()), sent, prompt), "", fmt.Errorf("kernel exited before completion")
+		default:
+		}
+		if time.Now().After(deadline) {
+			return scrubOutput(renderPTY(k.buf.String()), sent, prompt), "", fmt.Errorf("kernel timed out after %s", defaultTimeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (k *ptyKernel) Close() error {
+	k.ptmx.Close()
+	if k.cmd.Process != nil {
+		k.cmd.Process.Kill()
+	}
+	return k.cmd.Wait()
+}
@@ -0,0 +1,132 @@
+package akernel
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requireCommand skips the test if name isn't on $PATH, so the suite still
+// passes in a sandbox that only has some of these interpreters installed.
+func requireCommand(t *testing.T, name string) {
+	t.Helper()
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s not found on PATH: %v", name, err)
+	}
+}
+
+// TestKernelExecutePersistsState pumps two statements through the same
+// kernel and checks the second can see what the first declared, the core
+// promise a persistent kernel makes over a fresh interpreter per cell.
+func TestKernelExecutePersistsState(t *testing.T) {
+	cases := []struct {
+		language string
+		command  string
+		first    string
+		second   string
+		want     string
+	}{
+		{language: "bash", command: "bash", first: "x=5", second: "echo $x", want: "5"},
+		{language: "python", command: "python3", first: "x = 5", second: "print(x)", want: "5"},
+		// node's REPL echoes back each statement's own result in addition to
+		// whatever it printed; console.log's return value is undefined.
+		{language: "node", command: "node", first: "let x = 5", second: "console.log(x)", want: "5\nundefined"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.language, func(t *testing.T) {
+			requireCommand(t, c.command)
+			k, err := Spawn(c.language)
+			if err != nil {
+				t.Fatalf("Spawn(%q): %v", c.language, err)
+			}
+			defer k.Close()
+
+			if _, _, err := k.Execute(c.first); err != nil {
+				t.Fatalf("Execute(%q): %v", c.first, err)
+			}
+			out, _, err := k.Execute(c.second)
+			if err != nil {
+				t.Fatalf("Execute(%q): %v", c.second, err)
+			}
+			if got := strings.TrimSpace(out); got != c.want {
+				t.Errorf("Execute(%q) = %q, want %q", c.second, got, c.want)
+			}
+		})
+	}
+}
+
+// TestKernelExecuteScrubsPromptAndEcho is a regression test for Execute
+// returning raw PTY bytes: the result must contain only what the
+// interpreter printed, not the terminal's echo of the sent code or the
+// REPL's own prompt.
+func TestKernelExecuteScrubsPromptAndEcho(t *testing.T) {
+	cases := []struct {
+		language string
+		command  string
+		code     string
+		want     string
+	}{
+		{language: "bash", command: "bash", code: "echo hello; echo world", want: "hello\nworld"},
+		{language: "python", command: "python3", code: "print(1 + 1)", want: "2"},
+		// As above: node's REPL also echoes the statement's own (undefined)
+		// return value alongside what console.log printed.
+		{language: "node", command: "node", code: "console.log(1 + 1)", want: "2\nundefined"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.language, func(t *testing.T) {
+			requireCommand(t, c.command)
+			k, err := Spawn(c.language)
+			if err != nil {
+				t.Fatalf("Spawn(%q): %v", c.language, err)
+			}
+			defer k.Close()
+
+			out, _, err := k.Execute(c.code)
+			if err != nil {
+				t.Fatalf("Execute(%q): %v", c.code, err)
+			}
+			if got := strings.TrimSpace(out); got != c.want {
+				t.Errorf("Execute(%q) = %q, want %q (leaked prompt/echo?)", c.code, got, c.want)
+			}
+			if strings.Contains(out, c.code) {
+				t.Errorf("Execute(%q) output %q still contains the echoed input", c.code, out)
+			}
+		})
+	}
+}
+
+// TestKernelSpawnUnknownLanguage checks Spawn's error path for a language
+// with no registered langSpec.
+func TestKernelSpawnUnknownLanguage(t *testing.T) {
+	if _, err := Spawn("cobol"); err == nil {
+		t.Fatal("Spawn(\"cobol\") succeeded, want an error for an unregistered language")
+	}
+}
+
+// TestKernelCloseStopsFurtherOutput checks that Close terminates the
+// underlying process rather than leaving it running.
+func TestKernelCloseStopsFurtherOutput(t *testing.T) {
+	requireCommand(t, "bash")
+	k, err := Spawn("bash")
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	pk := k.(*ptyKernel)
+	if _, _, err := k.Execute("echo ready"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if err := k.Close(); err != nil {
+		t.Logf("Close: %v (bash was likely already killed by SIGTERM-equivalent, fine)", err)
+	}
+	if pk.cmd.ProcessState == nil {
+		t.Error("kernel process has not exited after Close")
+	}
+	select {
+	case <-pk.done:
+	case <-time.After(2 * time.Second):
+		t.Error("kernel output pump still running 2s after Close")
+	}
+}
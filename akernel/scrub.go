@@ -0,0 +1,80 @@
+package akernel
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tluyben/go-in-the-shell/aprocess"
+)
+
+// scrubWidth is the column width used to flatten a kernel's raw PTY bytes.
+// It only needs to be wide enough that no real output line wraps.
+const scrubWidth = 4096
+
+// renderPTY flattens raw (an xterm-style byte trace: CSI sequences, mode
+// toggles like bracketed paste, carriage returns and all) through a Screen
+// the same way a real terminal would, and returns the result split into
+// lines. This is what turns a REPL's escape-sequence noise into plain text.
+func renderPTY(raw string) []string {
+	height := strings.Count(raw, "\n") + 2
+	screen := aprocess.NewScreen(scrubWidth, height)
+	screen.Write([]byte(raw))
+	text := screen.String()
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// scrubOutput drops from lines the PTY's echo of sent (the code plus the
+// statement that printed the sentinel), with or without a leading REPL
+// prompt, and any line that is nothing but a prompt. Every other line is
+// kept as it is; a prompt-like prefix on such a line is not stripped.
+func scrubOutput(lines []string, sent string, prompt *regexp.Regexp) string {
+	var sentLines []string
+	for _, l := range strings.Split(sent, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			sentLines = append(sentLines, l)
+		}
+	}
+
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		reduced := line
+		promptMatched := false
+		if prompt != nil {
+			if loc := prompt.FindStringIndex(line); loc != nil {
+				promptMatched = true
+				reduced = prompt.ReplaceAllString(line, "")
+			}
+		}
+		reduced = strings.TrimSpace(reduced)
+
+		switch {
+		case isEcho(reduced, sentLines):
+			continue // the terminal's echo of what we sent it (possibly
+			// truncated mid-line, since output capture stops the moment the
+			// sentinel value appears)
+		case promptMatched && reduced == "":
+			continue // a bare prompt with nothing left after it
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// isEcho reports whether reduced is one of sentLines or a non-empty prefix
+// of one, the latter covering the sent line the capture was cut off in the
+// middle of.
+func isEcho(reduced string, sentLines []string) bool {
+	if reduced == "" {
+		return false
+	}
+	for _, sl := range sentLines {
+		if reduced == sl || strings.HasPrefix(sl, reduced) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package aprocess
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// killGrace is how long a cancelled streamed command gets to exit after
+// SIGTERM before Stop escalates to SIGKILL.
+const killGrace = 200 * time.Millisecond
+
+// StreamHandle is a running command started by ExecuteStream. Call Stop to
+// cancel it before replacing it with the next one, e.g. on every keystroke
+// of a live pipeline preview.
+type StreamHandle struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// Stop signals the command to terminate and blocks until it has exited,
+// trying SIGTERM first and falling back to SIGKILL after killGrace.
+func (h *StreamHandle) Stop() {
+	if h.cmd.Process != nil {
+		h.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-h.done:
+			return
+		case <-time.After(killGrace):
+			h.cmd.Process.Kill()
+		}
+	}
+	<-h.done
+}
+
+// ExecuteStream runs command with stdin as its standard input and invokes
+// onUpdate with everything the command has printed so far, each time more
+// output arrives, until the command exits. Unlike Execute it does not
+// attach a PTY or touch the real terminal: it is meant for non-interactive
+// pipeline commands whose output is displayed in a cell, not driven by the
+// user's keyboard.
+func ExecuteStream(command string, stdin []byte, onUpdate func(string)) (*StreamHandle, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting pipeline command: %v", err)
+	}
+
+	h := &StreamHandle{cmd: cmd, done: make(chan struct{})}
+
+	go func() {
+		var buf bytes.Buffer
+		chunk := make([]byte, 4096)
+		for {
+			n, err := pr.Read(chunk)
+			if n > 0 {
+				buf.Write(chunk[:n])
+				onUpdate(buf.String())
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		cmd.Wait()
+		pw.Close()
+		close(h.done)
+	}()
+
+	return h, nil
+}
+
+// ExecutePiped runs command once to completion with stdin as its standard
+// input and returns its combined output. It is the non-live counterpart to
+// ExecuteStream, used to replay a committed "#pipe" cell.
+func ExecutePiped(command string, stdin []byte) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return out.String(), err
+	}
+	return out.String(), nil
+}
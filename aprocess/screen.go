@@ -0,0 +1,747 @@
+package aprocess
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// parserState tracks where we are in the escape-sequence state machine:
+// Ground -> Escape -> Csi (back to Ground once the final byte arrives),
+// with a side channel for OSC strings. The one Csi state collects the
+// parameter, intermediate and final bytes; there are no separate
+// CsiEntry/CsiParam/CsiIntermediate states.
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateCsi
+	stateOsc
+)
+
+const trueColorBase = 1 << 24
+
+// cellAttr is the SGR rendition in effect when a cell was written.
+// fg/bg hold -1 for "default", 0-255 for a palette index (including the
+// 38;5/48;5 256-color form), or trueColorBase|rgb for 38;2/48;2 truecolor.
+type cellAttr struct {
+	bold      bool
+	underline bool
+	reverse   bool
+	fg        int
+	bg        int
+}
+
+var defaultAttr = cellAttr{fg: -1, bg: -1}
+
+// cell is zero-value (ch == 0) for the trailing column of a wide rune, so
+// String/render can skip it instead of emitting a spurious space. combining
+// holds any combining marks (e.g. U+0301 COMBINING ACUTE ACCENT) that were
+// written immediately after ch, so a decomposed base+mark sequence renders
+// as one merged grapheme instead of taking its own column.
+type cell struct {
+	ch        rune
+	combining []rune
+	attr      cellAttr
+}
+
+// Screen is an in-memory VT100/xterm-ish terminal buffer: a grid of cells
+// plus the cursor, scroll-region and mode state needed to interpret the
+// CSI/OSC/escape sequences a real shell or REPL emits.
+type Screen struct {
+	mu sync.Mutex
+
+	width, height int
+
+	primary [][]cell
+	alt     [][]cell
+	altMode bool
+
+	cursorX, cursorY int
+	cursorVisible    bool
+	pendingWrap      bool
+	autoWrap         bool
+
+	savedX, savedY int
+	savedAttr      cellAttr
+
+	curAttr cellAttr
+
+	scrollTop, scrollBottom int
+
+	tabStops map[int]bool
+
+	state      parserState
+	params     []string
+	curParam   strings.Builder
+	private    bool
+	oscBuf     strings.Builder
+	oscEscSeen bool
+	utf8Buf    []byte
+}
+
+func NewScreen(width, height int) *Screen {
+	s := &Screen{
+		width:         width,
+		height:        height,
+		cursorVisible: true,
+		autoWrap:      true,
+		curAttr:       defaultAttr,
+		savedAttr:     defaultAttr,
+		scrollBottom:  height - 1,
+	}
+	s.primary = newGrid(width, height)
+	s.alt = newGrid(width, height)
+	s.resetTabStops()
+	return s
+}
+
+func newGrid(width, height int) [][]cell {
+	grid := make([][]cell, height)
+	for i := range grid {
+		grid[i] = newRow(width)
+	}
+	return grid
+}
+
+func newRow(width int) []cell {
+	row := make([]cell, width)
+	for i := range row {
+		row[i] = cell{ch: ' ', attr: defaultAttr}
+	}
+	return row
+}
+
+func (s *Screen) resetTabStops() {
+	s.tabStops = make(map[int]bool)
+	for i := 0; i < s.width; i += 8 {
+		s.tabStops[i] = true
+	}
+}
+
+func (s *Screen) grid() [][]cell {
+	if s.altMode {
+		return s.alt
+	}
+	return s.primary
+}
+
+// Write feeds raw child-process output through the parser. It is safe to
+// call with output split across arbitrary byte boundaries, including in
+// the middle of an escape sequence or a multi-byte rune.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case stateGround:
+		s.feedGround(b)
+	case stateEscape:
+		s.feedEscape(b)
+	case stateCsi:
+		s.feedCsi(b)
+	case stateOsc:
+		s.feedOsc(b)
+	}
+}
+
+func (s *Screen) feedGround(b byte) {
+	switch b {
+	case 0x1b:
+		s.state = stateEscape
+		return
+	case '\r':
+		s.cursorX = 0
+		s.pendingWrap = false
+		return
+	case '\n', '\v', '\f':
+		s.newLine()
+		s.cursorX = 0
+		s.pendingWrap = false
+		return
+	case '\b':
+		if s.cursorX > 0 {
+			s.cursorX--
+		}
+		s.pendingWrap = false
+		return
+	case '\t':
+		s.tab()
+		return
+	case 0x07: // BEL
+		return
+	}
+	if b < 0x20 || b == 0x7f {
+		return // other C0 controls: ignored
+	}
+	if b < 0x80 {
+		s.putRune(rune(b))
+		return
+	}
+
+	// Multi-byte UTF-8: buffer until we have a full rune.
+	s.utf8Buf = append(s.utf8Buf, b)
+	if r, ok := decodeUTF8(s.utf8Buf); ok {
+		s.putRune(r)
+		s.utf8Buf = s.utf8Buf[:0]
+	} else if len(s.utf8Buf) >= 4 {
+		s.utf8Buf = s.utf8Buf[:0] // malformed sequence, drop it
+	}
+}
+
+func (s *Screen) feedEscape(b byte) {
+	switch b {
+	case '[':
+		s.state = stateCsi
+		s.params = nil
+		s.curParam.Reset()
+		s.private = false
+	case ']':
+		s.state = stateOsc
+		s.oscBuf.Reset()
+		s.oscEscSeen = false
+	case '7':
+		s.saveCursor()
+		s.state = stateGround
+	case '8':
+		s.restoreCursor()
+		s.state = stateGround
+	case 'D':
+		s.newLine()
+		s.state = stateGround
+	case 'M':
+		s.reverseIndex()
+		s.state = stateGround
+	case 'E':
+		s.newLine()
+		s.cursorX = 0
+		s.state = stateGround
+	case 'c':
+		s.reset()
+		s.state = stateGround
+	default:
+		s.state = stateGround
+	}
+}
+
+func (s *Screen) feedCsi(b byte) {
+	switch {
+	case b == '?':
+		s.private = true
+	case b >= '0' && b <= '9':
+		s.curParam.WriteByte(b)
+	case b == ';':
+		s.params = append(s.params, s.curParam.String())
+		s.curParam.Reset()
+	case b >= 0x20 && b <= 0x2f:
+		// intermediate byte; none of the sequences we handle need it
+	default:
+		s.params = append(s.params, s.curParam.String())
+		s.curParam.Reset()
+		s.execCsi(b)
+		s.state = stateGround
+		s.private = false
+	}
+}
+
+func (s *Screen) feedOsc(b byte) {
+	if s.oscEscSeen {
+		s.oscEscSeen = false
+		if b == '\\' {
+			s.state = stateGround
+			return
+		}
+		s.oscBuf.WriteByte(0x1b)
+	}
+	switch b {
+	case 0x07:
+		s.state = stateGround
+	case 0x1b:
+		s.oscEscSeen = true
+	default:
+		s.oscBuf.WriteByte(b)
+	}
+}
+
+func (s *Screen) tab() {
+	for x := s.cursorX + 1; x < s.width; x++ {
+		if s.tabStops[x] {
+			s.cursorX = x
+			return
+		}
+	}
+	s.cursorX = s.width - 1
+}
+
+func (s *Screen) saveCursor() {
+	s.savedX, s.savedY = s.cursorX, s.cursorY
+	s.savedAttr = s.curAttr
+}
+
+func (s *Screen) restoreCursor() {
+	s.cursorX, s.cursorY = s.savedX, s.savedY
+	s.curAttr = s.savedAttr
+	s.pendingWrap = false
+}
+
+func (s *Screen) reset() {
+	s.cursorX, s.cursorY = 0, 0
+	s.curAttr = defaultAttr
+	s.altMode = false
+	s.cursorVisible = true
+	s.autoWrap = true
+	s.pendingWrap = false
+	s.scrollTop, s.scrollBottom = 0, s.height-1
+	s.primary = newGrid(s.width, s.height)
+	s.alt = newGrid(s.width, s.height)
+	s.resetTabStops()
+}
+
+func (s *Screen) newLine() {
+	if s.cursorY == s.scrollBottom {
+		s.scrollUpRegion()
+	} else if s.cursorY < s.height-1 {
+		s.cursorY++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	if s.cursorY == s.scrollTop {
+		s.scrollDownRegion()
+	} else if s.cursorY > 0 {
+		s.cursorY--
+	}
+}
+
+func (s *Screen) scrollUpRegion() {
+	grid := s.grid()
+	for y := s.scrollTop; y < s.scrollBottom; y++ {
+		grid[y] = grid[y+1]
+	}
+	grid[s.scrollBottom] = newRow(s.width)
+}
+
+func (s *Screen) scrollDownRegion() {
+	grid := s.grid()
+	for y := s.scrollBottom; y > s.scrollTop; y-- {
+		grid[y] = grid[y-1]
+	}
+	grid[s.scrollTop] = newRow(s.width)
+}
+
+// putRune writes a single grapheme to the cursor position, handling
+// combining marks (merged into the previous cell rather than taking a
+// column of their own) and East-Asian-wide runes (which occupy two cells).
+func (s *Screen) putRune(r rune) {
+	if unicode.Is(unicode.Mn, r) {
+		s.mergeCombining(r)
+		return
+	}
+
+	if s.pendingWrap {
+		s.newLine()
+		s.cursorX = 0
+		s.pendingWrap = false
+	}
+
+	w := runeWidth(r)
+	grid := s.grid()
+	if s.cursorY >= 0 && s.cursorY < s.height && s.cursorX < s.width {
+		grid[s.cursorY][s.cursorX] = cell{ch: r, attr: s.curAttr}
+		if w == 2 && s.cursorX+1 < s.width {
+			grid[s.cursorY][s.cursorX+1] = cell{ch: 0, attr: s.curAttr}
+		}
+	}
+
+	s.cursorX += w
+	if s.cursorX >= s.width {
+		s.cursorX = s.width - 1
+		if s.autoWrap {
+			s.pendingWrap = true
+		}
+	}
+}
+
+// mergeCombining appends a combining mark to the most recently written
+// cell instead of giving it a column of its own, so e.g. a decomposed
+// "e" + U+0301 sequence renders as "é" rather than as "e" followed by a
+// dangling accent.
+func (s *Screen) mergeCombining(r rune) {
+	grid := s.grid()
+	x := s.cursorX
+	if !s.pendingWrap {
+		x--
+	}
+	if s.cursorY < 0 || s.cursorY >= s.height || x < 0 || x >= s.width {
+		return
+	}
+	if grid[s.cursorY][x].ch == 0 && x > 0 {
+		x-- // the trailing placeholder column of a wide rune
+	}
+	if grid[s.cursorY][x].ch == 0 {
+		return
+	}
+	grid[s.cursorY][x].combining = append(grid[s.cursorY][x].combining, r)
+}
+
+func (s *Screen) execCsi(final byte) {
+	switch final {
+	case 'A':
+		s.cursorY = clamp(s.cursorY-s.paramInt(0, 1), 0, s.height-1)
+	case 'B':
+		s.cursorY = clamp(s.cursorY+s.paramInt(0, 1), 0, s.height-1)
+	case 'C':
+		s.cursorX = clamp(s.cursorX+s.paramInt(0, 1), 0, s.width-1)
+	case 'D':
+		s.cursorX = clamp(s.cursorX-s.paramInt(0, 1), 0, s.width-1)
+	case 'G':
+		s.cursorX = clamp(s.paramInt(0, 1)-1, 0, s.width-1)
+	case 'd':
+		s.cursorY = clamp(s.paramInt(0, 1)-1, 0, s.height-1)
+	case 'H', 'f':
+		s.cursorY = clamp(s.paramInt(0, 1)-1, 0, s.height-1)
+		s.cursorX = clamp(s.paramInt(1, 1)-1, 0, s.width-1)
+		s.pendingWrap = false
+	case 'J':
+		s.eraseDisplay(s.paramInt(0, 0))
+	case 'K':
+		s.eraseLine(s.paramInt(0, 0))
+	case 'r':
+		top := clamp(s.paramInt(0, 1)-1, 0, s.height-1)
+		bottom := clamp(s.paramInt(1, s.height)-1, 0, s.height-1)
+		if bottom > top {
+			s.scrollTop, s.scrollBottom = top, bottom
+		} else {
+			s.scrollTop, s.scrollBottom = 0, s.height-1
+		}
+		// DECSTBM homes the cursor to row 1, col 1 (origin mode isn't
+		// implemented, so there's no scroll-region-relative home to use
+		// instead).
+		s.cursorX, s.cursorY = 0, 0
+	case 's':
+		if !s.private {
+			s.saveCursor()
+		}
+	case 'u':
+		if !s.private {
+			s.restoreCursor()
+		}
+	case 'g':
+		if s.paramInt(0, 0) == 3 {
+			s.tabStops = make(map[int]bool)
+		} else {
+			delete(s.tabStops, s.cursorX)
+		}
+	case 'm':
+		s.sgr()
+	case 'h', 'l':
+		s.setMode(final == 'h')
+	}
+}
+
+func (s *Screen) setMode(enable bool) {
+	if !s.private {
+		return
+	}
+	for _, p := range s.params {
+		switch p {
+		case "7":
+			s.autoWrap = enable
+		case "25":
+			s.cursorVisible = enable
+		case "1049":
+			if enable && !s.altMode {
+				s.altMode = true
+				s.alt = newGrid(s.width, s.height)
+				s.saveCursor()
+				s.cursorX, s.cursorY = 0, 0
+			} else if !enable && s.altMode {
+				s.altMode = false
+				s.restoreCursor()
+			}
+		}
+	}
+}
+
+func (s *Screen) eraseDisplay(mode int) {
+	grid := s.grid()
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for y := s.cursorY + 1; y < s.height; y++ {
+			grid[y] = newRow(s.width)
+		}
+	case 1:
+		s.eraseLine(1)
+		for y := 0; y < s.cursorY; y++ {
+			grid[y] = newRow(s.width)
+		}
+	case 2, 3:
+		for y := range grid {
+			grid[y] = newRow(s.width)
+		}
+	}
+}
+
+func (s *Screen) eraseLine(mode int) {
+	grid := s.grid()
+	row := grid[s.cursorY]
+	switch mode {
+	case 0:
+		for x := s.cursorX; x < s.width; x++ {
+			row[x] = cell{ch: ' ', attr: s.curAttr}
+		}
+	case 1:
+		for x := 0; x <= s.cursorX && x < s.width; x++ {
+			row[x] = cell{ch: ' ', attr: s.curAttr}
+		}
+	case 2:
+		grid[s.cursorY] = newRow(s.width)
+	}
+}
+
+func (s *Screen) sgr() {
+	ints := make([]int, len(s.params))
+	for i, p := range s.params {
+		if p == "" {
+			ints[i] = 0
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			n = 0
+		}
+		ints[i] = n
+	}
+	if len(ints) == 0 {
+		ints = []int{0}
+	}
+
+	for i := 0; i < len(ints); i++ {
+		code := ints[i]
+		switch {
+		case code == 0:
+			s.curAttr = defaultAttr
+		case code == 1:
+			s.curAttr.bold = true
+		case code == 4:
+			s.curAttr.underline = true
+		case code == 7:
+			s.curAttr.reverse = true
+		case code == 22:
+			s.curAttr.bold = false
+		case code == 24:
+			s.curAttr.underline = false
+		case code == 27:
+			s.curAttr.reverse = false
+		case code >= 30 && code <= 37:
+			s.curAttr.fg = code - 30
+		case code == 38:
+			if n, adv := s.extendedColor(ints, i+1); n >= 0 {
+				s.curAttr.fg = n
+				i += adv
+			}
+		case code == 39:
+			s.curAttr.fg = -1
+		case code >= 40 && code <= 47:
+			s.curAttr.bg = code - 40
+		case code == 48:
+			if n, adv := s.extendedColor(ints, i+1); n >= 0 {
+				s.curAttr.bg = n
+				i += adv
+			}
+		case code == 49:
+			s.curAttr.bg = -1
+		}
+	}
+}
+
+// extendedColor parses the `5;n` (256-color) or `2;r;g;b` (truecolor) tail
+// of an SGR 38/48 sequence starting at ints[from]. Returns the encoded
+// color and how many extra params it consumed, or -1 if malformed.
+func (s *Screen) extendedColor(ints []int, from int) (int, int) {
+	if from >= len(ints) {
+		return -1, 0
+	}
+	switch ints[from] {
+	case 5:
+		if from+1 < len(ints) {
+			return ints[from+1] & 0xff, 2
+		}
+	case 2:
+		if from+3 < len(ints) {
+			r, g, b := ints[from+1]&0xff, ints[from+2]&0xff, ints[from+3]&0xff
+			return trueColorBase | (r << 16) | (g << 8) | b, 4
+		}
+	}
+	return -1, 0
+}
+
+func (s *Screen) paramInt(idx, def int) int {
+	if idx >= len(s.params) || s.params[idx] == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s.params[idx])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// String renders the visible grid as plain text: trailing blanks trimmed
+// from each line, and trailing blank lines trimmed from the end.
+func (s *Screen) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.render(false)
+}
+
+// StringANSI renders the visible grid with SGR escapes reinstated so a
+// downstream viewer (e.g. a tview cell with dynamic colors) can reproduce
+// the original colors and attributes.
+func (s *Screen) StringANSI() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.render(true)
+}
+
+func (s *Screen) render(ansi bool) string {
+	grid := s.grid()
+	lines := make([]string, 0, len(grid))
+	for _, row := range grid {
+		var b strings.Builder
+		cur := defaultAttr
+		open := false
+		for _, c := range row {
+			if c.ch == 0 {
+				continue // trailing column of a wide rune
+			}
+			if ansi && c.attr != cur {
+				if open {
+					b.WriteString("\x1b[0m")
+				}
+				if code := sgrCode(c.attr); code != "" {
+					b.WriteString("\x1b[" + code + "m")
+					open = true
+				} else {
+					open = false
+				}
+				cur = c.attr
+			}
+			b.WriteRune(c.ch)
+			for _, m := range c.combining {
+				b.WriteRune(m)
+			}
+		}
+		if ansi && open {
+			b.WriteString("\x1b[0m")
+		}
+		lines = append(lines, strings.TrimRight(b.String(), " \t"))
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sgrCode(a cellAttr) string {
+	var parts []string
+	if a.bold {
+		parts = append(parts, "1")
+	}
+	if a.underline {
+		parts = append(parts, "4")
+	}
+	if a.reverse {
+		parts = append(parts, "7")
+	}
+	if a.fg >= 0 {
+		parts = append(parts, colorCode(a.fg, 30, 38))
+	}
+	if a.bg >= 0 {
+		parts = append(parts, colorCode(a.bg, 40, 48))
+	}
+	return strings.Join(parts, ";")
+}
+
+func colorCode(v, baseOffset, extended int) string {
+	if v >= trueColorBase {
+		rgb := v &^ trueColorBase
+		r, g, b := (rgb>>16)&0xff, (rgb>>8)&0xff, rgb&0xff
+		return strconv.Itoa(extended) + ";2;" + strconv.Itoa(r) + ";" + strconv.Itoa(g) + ";" + strconv.Itoa(b)
+	}
+	if v < 8 {
+		return strconv.Itoa(baseOffset + v)
+	}
+	return strconv.Itoa(extended) + ";5;" + strconv.Itoa(v)
+}
+
+func decodeUTF8(buf []byte) (rune, bool) {
+	if len(buf) == 0 {
+		return 0, false
+	}
+	b0 := buf[0]
+	var need int
+	switch {
+	case b0&0x80 == 0:
+		return rune(b0), true
+	case b0&0xe0 == 0xc0:
+		need = 2
+	case b0&0xf0 == 0xe0:
+		need = 3
+	case b0&0xf8 == 0xf0:
+		need = 4
+	default:
+		return 0xfffd, true
+	}
+	if len(buf) < need {
+		return 0, false
+	}
+	r := rune(b0 & (0xff >> uint(need+1)))
+	for _, cb := range buf[1:need] {
+		r = (r << 6) | rune(cb&0x3f)
+	}
+	return r, true
+}
+
+// runeWidth approximates wcwidth: 2 for East Asian Wide/Fullwidth runes,
+// 0 for NUL, 1 otherwise. Combining marks never reach it, since putRune
+// merges them into the previous cell first.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r == 0x2329, r == 0x232a,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe6f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	default:
+		return 1
+	}
+}
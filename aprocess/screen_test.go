@@ -0,0 +1,166 @@
+package aprocess
+
+import (
+	"strings"
+	"testing"
+)
+
+// goldenCase feeds raw (an xterm-style byte trace, escape sequences and
+// all) into a fresh Screen and checks the resulting String()/StringANSI().
+type goldenCase struct {
+	name   string
+	width  int
+	height int
+	raw    string
+	want   string
+	ansi   string // checked only if non-empty
+}
+
+func runGolden(t *testing.T, cases []goldenCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewScreen(c.width, c.height)
+			if _, err := s.Write([]byte(c.raw)); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got := s.String(); got != c.want {
+				t.Errorf("String() = %q, want %q", got, c.want)
+			}
+			if c.ansi != "" {
+				if got := s.StringANSI(); got != c.ansi {
+					t.Errorf("StringANSI() = %q, want %q", got, c.ansi)
+				}
+			}
+		})
+	}
+}
+
+func TestScreenPlainTextAndNewlines(t *testing.T) {
+	runGolden(t, []goldenCase{
+		{name: "plain text", width: 20, height: 5, raw: "hello", want: "hello"},
+		{name: "crlf", width: 20, height: 5, raw: "one\r\ntwo\r\nthree", want: "one\ntwo\nthree"},
+		{name: "bare lf also returns the cursor to column 0", width: 20, height: 5,
+			raw: "ab\ncd", want: "ab\ncd"},
+		{name: "backspace moves left", width: 20, height: 5, raw: "abc\bX", want: "abX"},
+	})
+}
+
+func TestScreenCursorMovement(t *testing.T) {
+	runGolden(t, []goldenCase{
+		{name: "cursor up", width: 10, height: 5, raw: "\n\n\x1b[2AX", want: "X"},
+		{name: "cursor down", width: 10, height: 5, raw: "\x1b[2BX", want: "\n\nX"},
+		{name: "cursor forward", width: 10, height: 5, raw: "\x1b[3CX", want: "   X"},
+		{name: "cursor to column (G)", width: 10, height: 5, raw: "\x1b[5GX", want: "    X"},
+		{name: "cursor position (H)", width: 10, height: 5, raw: "\x1b[3;3HX", want: "\n\n  X"},
+	})
+}
+
+func TestScreenEraseDisplayAndLine(t *testing.T) {
+	runGolden(t, []goldenCase{
+		{name: "erase to end of line", width: 10, height: 2, raw: "hello\x1b[3G\x1b[K", want: "he"},
+		{name: "erase whole line", width: 10, height: 2, raw: "hello\x1b[2K", want: ""},
+		{name: "erase display below cursor", width: 10, height: 3, raw: "aaa\naaa\naaa\x1b[2;1H\x1b[J", want: "aaa"},
+	})
+}
+
+// TestScreenDECSTBM is a regression test for the scroll-region-set cursor
+// home bug: setting a margin must move the cursor to row 1 col 1, not to
+// the top of the new scroll region.
+func TestScreenDECSTBM(t *testing.T) {
+	runGolden(t, []goldenCase{
+		{name: "sets margin and homes cursor to row 1", width: 20, height: 10,
+			raw: "\x1b[3;8rX", want: "X"},
+		{name: "scrolling stays within the configured region", width: 20, height: 5,
+			// Rows 2-4 (1-indexed) are the scroll region; writing four lines
+			// into it from its top (three newlines) scrolls that region once,
+			// dropping "a" and leaving row 1 (outside the region) untouched.
+			raw:  "top\x1b[2;4r\x1b[2;1Ha\nb\nc\nd",
+			want: "top\nb\nc\nd",
+		},
+	})
+}
+
+func TestScreenAltScreenBuffer(t *testing.T) {
+	runGolden(t, []goldenCase{
+		{name: "alt screen hides primary content and restores it on exit",
+			width: 10, height: 3,
+			raw:  "primary\x1b[?1049h alt \x1b[?1049l",
+			want: "primary",
+		},
+	})
+}
+
+func TestScreenCombiningMarksAndWideRunes(t *testing.T) {
+	runGolden(t, []goldenCase{
+		// "e" + U+0301 COMBINING ACUTE ACCENT (a decomposed "é") must merge
+		// into the previous cell rather than taking a column of its own.
+		{name: "combining mark merges into the previous cell", width: 10, height: 2,
+			raw: "é", want: "é"},
+		// The wide rune's trailing column is a zero-value placeholder cell
+		// that render() skips outright, so no gap appears before the next
+		// glyph even though the rune visually occupies two columns.
+		{name: "wide rune occupies two columns", width: 10, height: 2,
+			raw: "中X", want: "中X"},
+	})
+}
+
+func TestScreenSGRColorsRoundTrip(t *testing.T) {
+	s := NewScreen(20, 2)
+	s.Write([]byte("\x1b[1;31mred-bold\x1b[0m plain"))
+
+	if got := s.String(); got != "red-bold plain" {
+		t.Fatalf("String() = %q, want %q", got, "red-bold plain")
+	}
+
+	ansi := s.StringANSI()
+	if !strings.Contains(ansi, "\x1b[1;31m") {
+		t.Errorf("StringANSI() = %q, missing bold-red SGR", ansi)
+	}
+	if !strings.Contains(ansi, "red-bold") || !strings.Contains(ansi, "plain") {
+		t.Errorf("StringANSI() = %q, missing expected text", ansi)
+	}
+}
+
+func TestScreenExtendedColors(t *testing.T) {
+	s := NewScreen(30, 1)
+	s.Write([]byte("\x1b[38;5;200mfg256\x1b[48;2;10;20;30mbg-truecolor\x1b[0m"))
+	ansi := s.StringANSI()
+	if !strings.Contains(ansi, "38;5;200") {
+		t.Errorf("StringANSI() = %q, missing 256-color fg code", ansi)
+	}
+	if !strings.Contains(ansi, "48;2;10;20;30") {
+		t.Errorf("StringANSI() = %q, missing truecolor bg code", ansi)
+	}
+}
+
+// TestScreenSplitAcrossWrites checks that parser state (mid-escape-sequence
+// and mid-UTF-8-rune) survives being split across separate Write calls, the
+// way output from a real PTY arrives in arbitrarily sized chunks.
+func TestScreenSplitAcrossWrites(t *testing.T) {
+	s := NewScreen(20, 2)
+	chunks := []string{"\x1b[", "1", ";", "31", "m", "re", "d"}
+	for _, c := range chunks {
+		s.Write([]byte(c))
+	}
+	if got := s.String(); got != "red" {
+		t.Fatalf("String() = %q, want %q", got, "red")
+	}
+
+	s2 := NewScreen(20, 2)
+	r := "中" // U+4E2D, 3 UTF-8 bytes
+	b := []byte(r)
+	s2.Write(b[:1])
+	s2.Write(b[1:])
+	if got := s2.String(); got != r {
+		t.Fatalf("String() = %q, want %q", got, r)
+	}
+}
+
+func TestScreenTrailingBlankLinesTrimmed(t *testing.T) {
+	s := NewScreen(10, 5)
+	s.Write([]byte("only line"))
+	if got := s.String(); got != "only line" {
+		t.Fatalf("String() = %q, want %q", got, "only line")
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// highlightRules gives each language a single compiled pattern whose named
+// groups classify a match as a comment, string or keyword; anything that
+// doesn't match any group is left uncolored. This is deliberately a small
+// regex tokenizer rather than a real lexer — good enough to color cells in
+// the read-only view, not a claim of editor-grade syntax awareness.
+var highlightRules = map[string]*regexp.Regexp{
+	"python": buildHighlightPattern([]string{"def", "class", "import", "from", "return", "if", "elif", "else", "for", "while", "with", "as", "try", "except", "finally", "lambda", "None", "True", "False"}, `#[^\n]*`),
+	"bash":   buildHighlightPattern([]string{"if", "then", "else", "elif", "fi", "for", "while", "do", "done", "function", "case", "esac", "export", "local"}, `#[^\n]*`),
+	"node":   buildHighlightPattern([]string{"function", "const", "let", "var", "return", "if", "else", "for", "while", "class", "import", "export", "async", "await", "true", "false", "null"}, `//[^\n]*`),
+	"go":     buildHighlightPattern([]string{"func", "package", "import", "return", "if", "else", "for", "range", "var", "const", "type", "struct", "interface", "defer", "go", "chan", "map"}, `//[^\n]*`),
+	"perl":   buildHighlightPattern([]string{"my", "sub", "return", "if", "else", "elsif", "while", "for", "foreach", "use", "package"}, `#[^\n]*`),
+}
+
+func buildHighlightPattern(keywords []string, comment string) *regexp.Regexp {
+	kw := `\b(?:` + strings.Join(keywords, "|") + `)\b`
+	str := `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`
+	return regexp.MustCompile(`(?P<comment>` + comment + `)|(?P<string>` + str + `)|(?P<keyword>` + kw + `)`)
+}
+
+// highlightCode wraps keywords, strings and comments in the given language's
+// source in tview color tags, for display in the read-only cell view.
+func highlightCode(language, code string) string {
+	pattern, ok := highlightRules[language]
+	if !ok {
+		return tview.Escape(code)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringSubmatchIndex(code, -1) {
+		b.WriteString(tview.Escape(code[last:loc[0]]))
+		color, text := classifyMatch(pattern, code, loc)
+		fmt.Fprintf(&b, "[%s]%s[-]", color, tview.Escape(text))
+		last = loc[1]
+	}
+	b.WriteString(tview.Escape(code[last:]))
+	return b.String()
+}
+
+// classifyMatch picks the color for whichever named group of pattern
+// actually matched at loc, and returns the matched text along with it.
+func classifyMatch(pattern *regexp.Regexp, code string, loc []int) (string, string) {
+	for i, name := range pattern.SubexpNames() {
+		if name == "" || loc[2*i] < 0 {
+			continue
+		}
+		text := code[loc[2*i]:loc[2*i+1]]
+		switch name {
+		case "comment":
+			return "gray", text
+		case "string":
+			return "green", text
+		case "keyword":
+			return "yellow", text
+		}
+	}
+	return "white", code[loc[0]:loc[1]]
+}
+
+// historyDir returns the directory notebook history files live in,
+// honoring $XDG_DATA_HOME and falling back to ~/.local/share.
+func historyDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "go-in-the-shell"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "go-in-the-shell"), nil
+}
+
+// historyPath returns the history file for language, defaulting bare
+// bash/plain cells to "bash" the same way detectLanguage does.
+func historyPath(language string) (string, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return "", err
+	}
+	if language == "" {
+		language = "bash"
+	}
+	return filepath.Join(dir, "history-"+language), nil
+}
+
+// loadHistory reads language's history file, oldest entry first, unescaping
+// the newline-per-line encoding appendHistory writes.
+func loadHistory(language string) []string {
+	path, err := historyPath(language)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, `\n`, "\n")
+	}
+	return lines
+}
+
+// appendHistory records entry as the newest history for language. Embedded
+// newlines are escaped so the file stays one entry per line.
+func appendHistory(language, entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	path, err := historyPath(language)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(strings.ReplaceAll(entry, "\n", `\n`) + "\n")
+	return err
+}
+
+// searchHistory returns the most recent entry in history containing substr,
+// the same "walk backwards from the end" semantics as a shell's Ctrl-R.
+func searchHistory(history []string, substr string) string {
+	if substr == "" {
+		return ""
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if strings.Contains(history[i], substr) {
+			return history[i]
+		}
+	}
+	return ""
+}
+
+// longestCommonPrefix returns the longest string every entry in strs
+// starts with, used to do classic shell-style partial Tab completion.
+func longestCommonPrefix(strs []string) string {
+	if len(strs) == 0 {
+		return ""
+	}
+	prefix := strs[0]
+	for _, s := range strs[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+			if prefix == "" {
+				return ""
+			}
+		}
+	}
+	return prefix
+}
+
+// completions dispatches Tab completion on language: python completes
+// through the persistent kernel's rlcompleter, everything else falls back
+// to filesystem/$PATH completion the way a shell would.
+func (a *App) completions(language, prefix string) []string {
+	if language == "python" {
+		return a.pythonCompletions(prefix)
+	}
+	return bashCompletions(prefix)
+}
+
+// pythonCompletions asks the running python kernel to complete prefix via
+// the standard library's rlcompleter, so results reflect names actually
+// defined in that notebook's session rather than a static guess.
+func (a *App) pythonCompletions(prefix string) []string {
+	k, err := a.getKernel("python")
+	if err != nil {
+		return nil
+	}
+	code := fmt.Sprintf(`
+import rlcompleter as __rlc
+__completer = __rlc.Completer(globals())
+__i = 0
+while True:
+    __m = __completer.complete(%q, __i)
+    if __m is None:
+        break
+    print(__m)
+    __i += 1
+`, prefix)
+	out, _, err := k.Execute(code)
+	if err != nil || strings.TrimSpace(out) == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(out), "\n")
+}
+
+// bashCompletions completes prefix against the filesystem (if it looks like
+// a path) and against executables on $PATH, the two things a bash prompt
+// completes by default.
+func bashCompletions(prefix string) []string {
+	var out []string
+	if matches, err := filepath.Glob(prefix + "*"); err == nil {
+		out = append(out, matches...)
+	}
+	if !strings.ContainsRune(prefix, '/') {
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), prefix) {
+					out = append(out, e.Name())
+				}
+			}
+		}
+	}
+	return out
+}
+
+// openEditor opens the multi-line editor on the current cell's body
+// (language header stripped), seeding per-language history.
+func (a *App) openEditor() {
+	cell := &a.cells[a.currentCell]
+	language, body := detectLanguage(cell.content)
+	a.editorLanguage = language
+	a.editorHistory = loadHistory(language)
+	a.editorArea.SetText(body, true)
+	a.app.SetRoot(a.editorArea, true)
+}
+
+// submitEditor (Ctrl-Enter) commits the editor's text back to the current
+// cell and runs it. A body that itself starts with a "#<language>" header
+// overrides the language the editor was opened with, so switching language
+// mid-edit works the same way typing it directly into the cell always has.
+func (a *App) submitEditor() {
+	text := a.editorArea.GetText()
+	cell := &a.cells[a.currentCell]
+	if strings.HasPrefix(text, "#") {
+		cell.content = text
+	} else if a.editorLanguage != "" && a.editorLanguage != "bash" {
+		cell.content = "#" + a.editorLanguage + "\n" + text
+	} else {
+		cell.content = text
+	}
+	appendHistory(a.editorLanguage, text)
+	a.showLayout()
+	a.executeCurrentCell(true)
+}
+
+// cancelEditor (Esc) discards any edits and returns to the cell list.
+func (a *App) cancelEditor() {
+	a.showLayout()
+}
+
+// completeEditor (Tab) completes the last word of the editor's text in
+// place, using the longest common prefix of all matches.
+func (a *App) completeEditor() {
+	text := a.editorArea.GetText()
+	idx := strings.LastIndexAny(text, " \t\n")
+	prefix := text[idx+1:]
+	if prefix == "" {
+		return
+	}
+	matches := a.completions(a.editorLanguage, prefix)
+	if len(matches) == 0 {
+		return
+	}
+	common := longestCommonPrefix(matches)
+	if len(common) > len(prefix) {
+		a.editorArea.SetText(text[:idx+1]+common, true)
+	}
+}
+
+// openHistorySearch (Ctrl-R) switches root to historyField for an
+// incremental reverse-history search, the same interaction as a shell's.
+func (a *App) openHistorySearch() {
+	a.historyField.SetText("")
+	a.app.SetRoot(a.historyField, true)
+}
+
+// newEditorArea builds the multi-line cell editor: Enter inserts a newline
+// (tview.TextArea's default), Ctrl-Enter executes, Esc cancels, Ctrl-R opens
+// reverse-history search and Tab completes.
+func newEditorArea(a *App) *tview.TextArea {
+	area := tview.NewTextArea()
+	area.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEnter:
+			if event.Modifiers()&tcell.ModCtrl != 0 {
+				a.submitEditor()
+				return nil
+			}
+			return event
+		case tcell.KeyEsc:
+			a.cancelEditor()
+			return nil
+		case tcell.KeyCtrlR:
+			a.openHistorySearch()
+			return nil
+		case tcell.KeyTab:
+			a.completeEditor()
+			return nil
+		}
+		return event
+	})
+	return area
+}
+
+// newHistoryField builds the reverse-history search prompt opened by
+// Ctrl-R: typing narrows to the most recent matching history entry, Enter
+// accepts it into the editor, Esc returns to the editor unchanged.
+func newHistoryField(a *App) *tview.InputField {
+	field := tview.NewInputField().SetLabel("(reverse-i-search): ")
+	field.SetChangedFunc(func(text string) {
+		a.historyMatch = searchHistory(a.editorHistory, text)
+	})
+	field.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter && a.historyMatch != "" {
+			a.editorArea.SetText(a.historyMatch, true)
+		}
+		a.app.SetRoot(a.editorArea, true)
+	})
+	return field
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tluyben/go-in-the-shell/akernel"
+	"github.com/tluyben/go-in-the-shell/aprocess"
+	"github.com/tluyben/go-in-the-shell/notebook"
+)
+
+// runHeadless executes every cell of the notebook at inPath in order,
+// without starting the tview UI, writes the results back to outPath
+// (inPath if outPath is empty), and optionally exports HTML to htmlPath.
+// It returns a process exit code: 0 if every cell ran without error, 1 if
+// any cell failed, a file couldn't be read/written, or the notebook
+// couldn't be parsed.
+func runHeadless(inPath, outPath, htmlPath string) int {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", inPath, err)
+		return 1
+	}
+
+	nb, err := notebook.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", inPath, err)
+		return 1
+	}
+
+	kernels := make(map[string]akernel.Kernel)
+	defer func() {
+		for _, k := range kernels {
+			k.Close()
+		}
+	}()
+
+	var goCells []string
+	exitCode := 0
+
+	for i := range nb.Cells {
+		cell := &nb.Cells[i]
+		language := cell.Language
+		if language == "" {
+			language = "bash"
+		}
+
+		result, err := runHeadlessCell(language, cell, i, nb, kernels, &goCells)
+		if err != nil {
+			result = fmt.Sprintf("Error: %v\n%s", err, result)
+			exitCode = 1
+		}
+		cell.Result = result
+	}
+
+	if outPath == "" {
+		outPath = inPath
+	}
+	if err := os.WriteFile(outPath, nb.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", outPath, err)
+		return 1
+	}
+
+	if htmlPath != "" {
+		if err := os.WriteFile(htmlPath, []byte(notebook.ExportHTML(nb)), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", htmlPath, err)
+			return 1
+		}
+	}
+
+	return exitCode
+}
+
+// runHeadlessCell executes a single notebook cell. Commands run through
+// aprocess.ExecutePiped rather than aprocess.Execute because headless mode
+// has no real terminal to hand over to an interactive child.
+func runHeadlessCell(language string, cell *notebook.Cell, index int, nb *notebook.Notebook, kernels map[string]akernel.Kernel, goCells *[]string) (string, error) {
+	switch {
+	case persistentLanguages[language]:
+		k, ok := kernels[language]
+		if !ok {
+			var err error
+			k, err = akernel.Spawn(language)
+			if err != nil {
+				return "", err
+			}
+			kernels[language] = k
+		}
+		stdout, stderr, err := k.Execute(cell.Content)
+		if stderr != "" {
+			stdout += "\n" + stderr
+		}
+		return stdout, err
+
+	case language == "pipe":
+		var upstream []byte
+		if index > 0 {
+			upstream = []byte(nb.Cells[index-1].Result)
+		}
+		return aprocess.ExecutePiped(strings.TrimSpace(cell.Content), upstream)
+
+	case language == "go":
+		*goCells = append(*goCells, cell.Content)
+		return runGoSource(buildGoSourceFromCells(*goCells))
+
+	case language == "perl":
+		return aprocess.ExecutePiped(fmt.Sprintf("perl -e %q", cell.Content), nil)
+
+	default:
+		return aprocess.ExecutePiped(cell.Content, nil)
+	}
+}
+
+// runGoSource writes source to a temp file and runs it with `go run`,
+// mirroring App's interactive Go cell handling but without a PTY.
+func runGoSource(source string) (string, error) {
+	tmpfile, err := os.CreateTemp("", "cell-*.go")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(source)); err != nil {
+		return "", fmt.Errorf("writing temp file: %v", err)
+	}
+	tmpfile.Close()
+
+	return aprocess.ExecutePiped(fmt.Sprintf("go run %s", tmpfile.Name()), nil)
+}
@@ -8,22 +8,72 @@ import (
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/tluyben/go-in-the-shell/akernel"
 	"github.com/tluyben/go-in-the-shell/aprocess"
+	"github.com/tluyben/go-in-the-shell/notebook"
 	"golang.org/x/term"
 )
 
+// persistentLanguages are run against a long-lived akernel.Kernel instead
+// of a fresh interpreter per cell, so variables/state carry over between
+// cells the way they would in a real notebook.
+var persistentLanguages = map[string]bool{
+	"bash":   true,
+	"python": true,
+	"node":   true,
+	"irb":    true,
+	"psql":   true,
+}
+
+// pipeBufferCap bounds how much of the upstream cell's result is snapshot
+// into memory and piped into a live preview command.
+const pipeBufferCap = 4 * 1024 * 1024
+
 type Cell struct {
-	content string
-	result  string
+	content  string
+	result   string
+	language string
+	status   string // "", "running", "error" — shown next to the cell header
 }
 
 type App struct {
-	cells       []Cell
-	currentCell int
-	app         *tview.Application
-	textView    *tview.TextView
-	inputField  *tview.InputField
-	darkMode    bool
+	cells        []Cell
+	currentCell  int
+	app          *tview.Application
+	commandField *tview.InputField
+	darkMode     bool
+
+	// panes, root and paneNodes implement the split-pane layout: root is
+	// the split tree, paneNodes maps each live Pane back to its node in
+	// that tree, panes lists every Pane that needs re-rendering, focused
+	// is whichever pane last received a key, and paneCmdPending is set
+	// while waiting for the command key after Ctrl-W.
+	panes          []*Pane
+	root           *paneNode
+	paneNodes      map[*Pane]*paneNode
+	focused        *Pane
+	paneCmdPending bool
+
+	editorArea     *tview.TextArea
+	editorLanguage string
+	editorHistory  []string
+
+	historyField *tview.InputField
+	historyMatch string
+
+	kernels map[string]akernel.Kernel
+
+	// goCells holds the latest Go source submitted for each cell index, so
+	// re-running an edited cell replaces its contribution to the
+	// accumulated program instead of appending a second copy of it.
+	goCells map[int]string
+
+	pipeField    *tview.InputField
+	pipeHandle   *aprocess.StreamHandle
+	pipeUpstream []byte
+
+	saveField    *tview.InputField
+	notebookPath string
 }
 
 func NewApp(darkMode bool) *App {
@@ -32,136 +82,144 @@ func NewApp(darkMode bool) *App {
 		currentCell: 0,
 		app:         tview.NewApplication(),
 		darkMode:    darkMode,
+		kernels:     make(map[string]akernel.Kernel),
+		goCells:     make(map[int]string),
 	}
 }
 
 func (a *App) Run() error {
-	a.textView = tview.NewTextView().
-		SetDynamicColors(true).
-		SetRegions(true).
-		SetChangedFunc(func() {
-			a.app.Draw()
+	a.initPanes()
+
+	a.editorArea = newEditorArea(a)
+	a.historyField = newHistoryField(a)
+
+	a.commandField = tview.NewInputField().
+		SetLabel(":").
+		SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEnter {
+				a.runCommand(a.commandField.GetText())
+			}
+			a.commandField.SetText("")
+			a.showLayout()
 		})
 
-	a.inputField = tview.NewInputField().
-		SetLabel("Edit: ").
+	a.pipeField = tview.NewInputField().
+		SetLabel("Pipe: ").
+		SetChangedFunc(func(text string) {
+			a.runPipePreview(text)
+		})
+	a.pipeField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyCtrlX:
+			a.commitPipePreview()
+			return nil
+		case tcell.KeyEsc:
+			a.resetPipePreview()
+			return nil
+		}
+		return event
+	})
+
+	a.saveField = tview.NewInputField().
+		SetLabel("Save to: ").
 		SetDoneFunc(func(key tcell.Key) {
 			if key == tcell.KeyEnter {
-				a.cells[a.currentCell].content = a.inputField.GetText()
-				a.executeCurrentCell(true)
-				a.app.SetRoot(a.textView, true)
-			} else if key == tcell.KeyEsc {
-				a.app.SetRoot(a.textView, true)
+				if err := a.saveNotebook(a.saveField.GetText()); err != nil {
+					a.cells[a.currentCell].result = fmt.Sprintf("Error saving: %v", err)
+				}
 			}
+			a.showLayout()
+			a.updateView()
 		})
 
 	// Set colors based on mode
 	if a.darkMode {
-		a.textView.SetTextColor(tcell.ColorWhite).SetBackgroundColor(tcell.ColorBlack)
-		a.inputField.SetFieldTextColor(tcell.ColorWhite).
+		a.editorArea.SetTextStyle(tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorBlack))
+		a.commandField.SetFieldTextColor(tcell.ColorWhite).
+			SetFieldBackgroundColor(tcell.ColorBlack).
+			SetLabelColor(tcell.ColorWhite)
+		a.pipeField.SetFieldTextColor(tcell.ColorWhite).
+			SetFieldBackgroundColor(tcell.ColorBlack).
+			SetLabelColor(tcell.ColorWhite)
+		a.saveField.SetFieldTextColor(tcell.ColorWhite).
+			SetFieldBackgroundColor(tcell.ColorBlack).
+			SetLabelColor(tcell.ColorWhite)
+		a.historyField.SetFieldTextColor(tcell.ColorWhite).
 			SetFieldBackgroundColor(tcell.ColorBlack).
 			SetLabelColor(tcell.ColorWhite)
 	} else {
-		a.textView.SetTextColor(tcell.ColorBlack).SetBackgroundColor(tcell.ColorWhite)
-		a.inputField.SetFieldTextColor(tcell.ColorBlack).
+		a.editorArea.SetTextStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorWhite))
+		a.commandField.SetFieldTextColor(tcell.ColorBlack).
+			SetFieldBackgroundColor(tcell.ColorWhite).
+			SetLabelColor(tcell.ColorBlack)
+		a.pipeField.SetFieldTextColor(tcell.ColorBlack).
+			SetFieldBackgroundColor(tcell.ColorWhite).
+			SetLabelColor(tcell.ColorBlack)
+		a.saveField.SetFieldTextColor(tcell.ColorBlack).
+			SetFieldBackgroundColor(tcell.ColorWhite).
+			SetLabelColor(tcell.ColorBlack)
+		a.historyField.SetFieldTextColor(tcell.ColorBlack).
 			SetFieldBackgroundColor(tcell.ColorWhite).
 			SetLabelColor(tcell.ColorBlack)
 	}
 
-	a.textView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyUp:
-			a.moveUp()
-		case tcell.KeyDown:
-			a.moveDown()
-		case tcell.KeyEnter:
-			a.executeCurrentCell(true)
-		case tcell.KeyBackspace, tcell.KeyBackspace2:
-			a.removeCurrentCell()
-		case tcell.KeyRune:
-			switch event.Rune() {
-			case ' ':
-				a.editInline()
-			case 'v':
-				a.editWithVim()
-			case '+':
-				a.copyCurrentCell()
-			}
-		}
-		return event
-	})
-
 	a.updateView()
+	a.showLayout()
 
-	return a.app.SetRoot(a.textView, true).Run()
+	return a.app.Run()
 }
 
-func (a *App) updateView() {
-	a.textView.Clear()
-	totalLines := 0
-	selectedCellStart := 0
-	for i, cell := range a.cells {
-		if i == a.currentCell {
-			selectedCellStart = totalLines
-		}
-		fmt.Fprintf(a.textView, "[\"cell-%d\"][%d]:[\"\"]\n", i+1, i+1)
-		totalLines++
-		contentLines := strings.Count(cell.content, "\n") + 1
-		resultLines := strings.Count(cell.result, "\n") + 1
-		fmt.Fprintf(a.textView, "%s", cell.content)
-		totalLines += contentLines
-		if cell.result != "" {
-			fmt.Fprintf(a.textView, "\n\n%s", cell.result)
-			totalLines += resultLines + 2
-		}
-		fmt.Fprintf(a.textView, "\n\n")
-		totalLines += 2
-	}
-	a.textView.Highlight(fmt.Sprintf("cell-%d", a.currentCell+1))
-	
-	// Calculate the scroll position
-	_, _, _, viewHeight := a.textView.GetInnerRect()
-	scrollPosition := selectedCellStart - viewHeight/2
-	if scrollPosition < 0 {
-		scrollPosition = 0
-	}
-	a.textView.ScrollTo(scrollPosition, 0)
+// runCommand handles ":restart <language>" and ":kill <language>", typed
+// into the command field opened with ':'. Both terminate the kernel for
+// that language; a restarted kernel is respawned lazily on its next cell.
+func (a *App) runCommand(line string) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return
+	}
+	switch fields[0] {
+	case "restart", "kill":
+		a.restartKernel(fields[1])
+		a.updateView()
+	}
 }
 
 func (a *App) moveUp() {
 	if a.currentCell > 0 {
 		a.currentCell--
-		a.updateView()
+		a.syncCursor()
 	}
 }
 
 func (a *App) moveDown() {
 	if a.currentCell < len(a.cells)-1 {
 		a.currentCell++
-		a.updateView()
+		a.syncCursor()
 	}
 }
 func (a *App) executeCurrentCell(doSuspend bool) {
 	cell := &a.cells[a.currentCell]
-	content := cell.content
+	language, content := detectLanguage(cell.content)
+	cell.language = language
 
-	// Determine the language and content
-	language := "bash"
-	if strings.HasPrefix(content, "#") {
-		parts := strings.SplitN(content, "\n", 2)
-		if len(parts) == 2 {
-			language = strings.TrimPrefix(parts[0], "#")
-			content = parts[1]
-		}
+	if persistentLanguages[language] {
+		a.executeInKernel(cell, language, content)
+		return
+	}
+
+	if language == "pipe" {
+		a.executePipeCell(cell, content)
+		return
 	}
 
 	// Prepare the command based on the language
 	var command string
 	switch language {
-	case "python":
-		command = fmt.Sprintf("python -c %q", content)
 	case "go":
-		// For Go, we need to create a temporary file
+		// Go cells accumulate: each cell's statements are appended to the
+		// notebook's running main() so earlier declarations stay in scope.
+		source := a.buildGoProgram(a.currentCell, content)
+
 		tmpfile, err := os.CreateTemp("", "cell-*.go")
 		if err != nil {
 			cell.result = fmt.Sprintf("Error creating temp file: %v", err)
@@ -170,7 +228,7 @@ func (a *App) executeCurrentCell(doSuspend bool) {
 		}
 		defer os.Remove(tmpfile.Name())
 
-		if _, err := tmpfile.Write([]byte(content)); err != nil {
+		if _, err := tmpfile.Write([]byte(source)); err != nil {
 			cell.result = fmt.Sprintf("Error writing to temp file: %v", err)
 			a.updateView()
 			return
@@ -185,7 +243,7 @@ func (a *App) executeCurrentCell(doSuspend bool) {
 	}
 
 	// Suspend the application
-	if (!doSuspend) {
+	if !doSuspend {
 		output, err := aprocess.Execute(command)
 		if err != nil {
 			cell.result = fmt.Sprintf("Error: %v\n%s", err, output)
@@ -193,13 +251,7 @@ func (a *App) executeCurrentCell(doSuspend bool) {
 			cell.result = output
 		}
 
-		// Move to the next cell or create a new one if at the end
-		if a.currentCell == len(a.cells)-1 {
-			a.cells = append(a.cells, Cell{content: "", result: ""})
-		}
-
-		a.currentCell++
-
+		a.advanceCell()
 		a.updateView()
 	} else {
 		a.app.Suspend(func() {
@@ -220,35 +272,237 @@ func (a *App) executeCurrentCell(doSuspend bool) {
 				cell.result = output
 			}
 
-			// Move to the next cell or create a new one if at the end
-			if a.currentCell == len(a.cells)-1 {
-				a.cells = append(a.cells, Cell{content: "", result: ""})
-			}
+			a.advanceCell()
+			a.updateView()
+		})
+	}
+
+}
+
+// detectLanguage reads a cell's language header, if any: a leading
+// "#<language>\n" line names the language explicitly, otherwise the
+// content is treated as plain bash. It returns the language and the
+// remaining content with that header line stripped.
+func detectLanguage(content string) (string, string) {
+	if strings.HasPrefix(content, "#") {
+		parts := strings.SplitN(content, "\n", 2)
+		if len(parts) == 2 {
+			return strings.TrimPrefix(parts[0], "#"), parts[1]
+		}
+	}
+	return "bash", content
+}
+
+// advanceCell moves to the next cell, appending a fresh one if we were on
+// the last cell. Shared by every execution path so the "what happens after
+// a cell runs" behavior stays in one place.
+func (a *App) advanceCell() {
+	if a.currentCell == len(a.cells)-1 {
+		a.cells = append(a.cells, Cell{content: "", result: ""})
+	}
+	a.currentCell++
+	a.syncCursor()
+}
+
+// executeInKernel runs content against the persistent kernel for language,
+// spawning one lazily if this is the first cell to use it.
+func (a *App) executeInKernel(cell *Cell, language, content string) {
+	cell.status = "running"
+	a.updateView()
+
+	k, err := a.getKernel(language)
+	if err != nil {
+		cell.status = "error"
+		cell.result = fmt.Sprintf("Error: %v", err)
+		a.advanceCell()
+		a.updateView()
+		return
+	}
+
+	stdout, stderr, err := k.Execute(content)
+	if err != nil {
+		cell.status = "error"
+		cell.result = fmt.Sprintf("Error: %v\n%s", err, stdout)
+	} else {
+		cell.status = ""
+		cell.result = stdout
+		if stderr != "" {
+			cell.result += "\n" + stderr
+		}
+	}
 
-			a.currentCell++
+	a.advanceCell()
+	a.updateView()
+}
+
+// executePipeCell replays a committed "#pipe" cell once against the
+// upstream cell's result, the non-live counterpart to the interactive
+// preview started with startPipePreview.
+func (a *App) executePipeCell(cell *Cell, content string) {
+	var upstream []byte
+	if a.currentCell > 0 {
+		upstream = []byte(a.cells[a.currentCell-1].result)
+	}
+
+	out, err := aprocess.ExecutePiped(strings.TrimSpace(content), upstream)
+	if err != nil {
+		cell.result = fmt.Sprintf("Error: %v\n%s", err, out)
+	} else {
+		cell.result = out
+	}
+
+	a.advanceCell()
+	a.updateView()
+}
+
+// startPipePreview opens the live pipeline-preview field for the current
+// cell: the previous cell's result is snapshotted as stdin, and every
+// keystroke re-runs the typed command against it, streaming partial
+// output into the cell as it arrives.
+func (a *App) startPipePreview() {
+	var upstream []byte
+	if a.currentCell > 0 {
+		upstream = []byte(a.cells[a.currentCell-1].result)
+	}
+	if len(upstream) > pipeBufferCap {
+		upstream = upstream[:pipeBufferCap]
+	}
+	a.pipeUpstream = upstream
+
+	a.pipeField.SetText(a.cells[a.currentCell].content)
+	a.app.SetRoot(a.pipeField, true)
+}
+
+// runPipePreview cancels the previous preview command, if any, and starts
+// the newly typed one against the buffered upstream snapshot. It runs on
+// every keystroke in the pipe field, so cancelling the old command happens
+// in the background instead of blocking that draw cycle on however long
+// the old command takes to die (Stop waits out killGrace before it can
+// escalate to SIGKILL).
+func (a *App) runPipePreview(text string) {
+	if a.pipeHandle != nil {
+		go a.pipeHandle.Stop()
+		a.pipeHandle = nil
+	}
+
+	cell := &a.cells[a.currentCell]
+	if strings.TrimSpace(text) == "" {
+		cell.result = ""
+		a.updateView()
+		return
+	}
 
+	h, err := aprocess.ExecuteStream(text, a.pipeUpstream, func(output string) {
+		a.app.QueueUpdateDraw(func() {
+			cell.result = output
 			a.updateView()
+		})
+	})
+	if err != nil {
+		cell.result = fmt.Sprintf("Error: %v", err)
+		a.updateView()
+		return
+	}
+	a.pipeHandle = h
+}
+
+// commitPipePreview (Ctrl-X) freezes the live preview as a normal "#pipe"
+// cell and moves on to the next one.
+func (a *App) commitPipePreview() {
+	if a.pipeHandle != nil {
+		a.pipeHandle.Stop()
+		a.pipeHandle = nil
+	}
+
+	cell := &a.cells[a.currentCell]
+	cell.content = "#pipe\n" + a.pipeField.GetText()
+	cell.language = "pipe"
+
+	a.advanceCell()
+	a.showLayout()
+	a.updateView()
+}
+
+// resetPipePreview (Esc) cancels the preview command and clears the cell.
+func (a *App) resetPipePreview() {
+	if a.pipeHandle != nil {
+		a.pipeHandle.Stop()
+		a.pipeHandle = nil
+	}
 
-			
-		})	
+	cell := &a.cells[a.currentCell]
+	cell.content = ""
+	cell.result = ""
+
+	a.pipeField.SetText("")
+	a.showLayout()
+	a.updateView()
+}
+
+// getKernel returns the running kernel for language, spawning it on first use.
+func (a *App) getKernel(language string) (akernel.Kernel, error) {
+	if k, ok := a.kernels[language]; ok {
+		return k, nil
 	}
+	k, err := akernel.Spawn(language)
+	if err != nil {
+		return nil, err
+	}
+	a.kernels[language] = k
+	return k, nil
+}
 
+// restartKernel kills and forgets the kernel for language, if one is running.
+// The next cell that needs it spawns a fresh one.
+func (a *App) restartKernel(language string) {
+	if k, ok := a.kernels[language]; ok {
+		k.Close()
+		delete(a.kernels, language)
+	}
 }
-func (a *App) editInline() {
-	a.inputField.SetText(a.cells[a.currentCell].content)
-	
-	a.app.SetRoot(a.inputField, true)
+
+// buildGoProgram records content as cell index's contribution to the
+// notebook's accumulated Go source, replacing whatever that cell
+// contributed last time it ran, and returns a complete "package main" file
+// containing every cell's statements in cell order inside one func main(),
+// so variables declared in earlier cells are still in scope and re-running
+// an edited cell doesn't duplicate its old content.
+func (a *App) buildGoProgram(index int, content string) string {
+	a.goCells[index] = content
+	cells := make([]string, 0, len(a.goCells))
+	for i := 0; i < len(a.cells); i++ {
+		if c, ok := a.goCells[i]; ok {
+			cells = append(cells, c)
+		}
+	}
+	return buildGoSourceFromCells(cells)
 }
 
+// buildGoSourceFromCells wraps every accumulated Go cell's statements, in
+// order, into the body of func main() in a single "package main" file, so
+// variables declared in earlier cells are still in scope for later ones. A
+// cell cannot hold top-level declarations (an import, a named func), since
+// its text ends up inside main().
+func buildGoSourceFromCells(cells []string) string {
+	var body strings.Builder
+	body.WriteString("package main\n\nimport \"fmt\"\n\nfunc main() {\n")
+	body.WriteString("\t_ = fmt.Sprint\n")
+	for _, c := range cells {
+		body.WriteString(c)
+		body.WriteString("\n")
+	}
+	body.WriteString("}\n")
+	return body.String()
+}
 func (a *App) editWithVim() {
 	cell := &a.cells[a.currentCell]
-	
+
 	// Determine the language and file extension
 	language := "txt"
 	if strings.HasPrefix(cell.content, "#") {
 		language = strings.TrimPrefix(strings.SplitN(cell.content, "\n", 2)[0], "#")
 	}
-	
+
 	tmpfile, err := os.CreateTemp("", fmt.Sprintf("cell-*.%s", getFileExtension(language)))
 	if err != nil {
 		cell.result = fmt.Sprintf("Error creating temp file: %v", err)
@@ -292,13 +546,10 @@ func (a *App) editWithVim() {
 			a.executeCurrentCell(false)
 		}
 
-
 		a.updateView()
 
-		
 	})
 
-
 }
 
 func (a *App) copyCurrentCell() {
@@ -313,12 +564,12 @@ func (a *App) removeCurrentCell() {
 	if len(a.cells) > 1 {
 		// Remove the current cell
 		a.cells = append(a.cells[:a.currentCell], a.cells[a.currentCell+1:]...)
-		
+
 		// Adjust the current cell index if necessary
 		if a.currentCell >= len(a.cells) {
 			a.currentCell = len(a.cells) - 1
 		}
-		
+
 		a.updateView()
 	}
 }
@@ -336,19 +587,94 @@ func getFileExtension(language string) string {
 	}
 }
 
+// loadNotebook replaces the current cells with the ones parsed from the
+// notebook file at path, and remembers path as the default Ctrl-S target.
+func (a *App) loadNotebook(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	nb, err := notebook.Parse(data)
+	if err != nil {
+		return err
+	}
+	a.cells = notebookToCells(nb)
+	a.currentCell = 0
+	a.notebookPath = path
+	return nil
+}
+
+// saveNotebook writes the current cells to path in notebook format and
+// remembers path as the default Ctrl-S target.
+func (a *App) saveNotebook(path string) error {
+	if err := os.WriteFile(path, cellsToNotebook(a.cells).Bytes(), 0644); err != nil {
+		return err
+	}
+	a.notebookPath = path
+	return nil
+}
+
+// cellsToNotebook converts in-app cells to the notebook file format,
+// moving the "#<language>\n" header (if any) out of the content and into
+// the fenced block's info string so it isn't stored twice.
+func cellsToNotebook(cells []Cell) *notebook.Notebook {
+	nb := &notebook.Notebook{}
+	for _, c := range cells {
+		language, content := detectLanguage(c.content)
+		nb.Cells = append(nb.Cells, notebook.Cell{Language: language, Content: content, Result: c.result})
+	}
+	return nb
+}
+
+// notebookToCells converts parsed notebook cells back into in-app cells,
+// reinstating the "#<language>\n" header that detectLanguage expects for
+// anything other than plain bash.
+func notebookToCells(nb *notebook.Notebook) []Cell {
+	cells := make([]Cell, 0, len(nb.Cells))
+	for _, c := range nb.Cells {
+		content := c.Content
+		if c.Language != "" && c.Language != "bash" {
+			content = "#" + c.Language + "\n" + content
+		}
+		cells = append(cells, Cell{content: content, result: c.Result, language: c.Language})
+	}
+	if len(cells) == 0 {
+		cells = append(cells, Cell{})
+	}
+	return cells
+}
+
 func main() {
 	// Define command-line flags
 	lightMode := flag.Bool("light", true, "Use light mode (default)")
 	darkMode := flag.Bool("dark", false, "Use dark mode")
+	openPath := flag.String("open", "", "Open a notebook file on startup")
+	savePath := flag.String("save", "", "Default path used when saving the notebook (Ctrl-S)")
+	executePath := flag.String("execute", "", "Headless: run every cell of this notebook file without the UI")
+	outPath := flag.String("o", "", "Output path for -execute (defaults to the input file)")
+	htmlPath := flag.String("html", "", "With -execute, also export the notebook to this HTML file")
 
 	// Parse command-line flags
 	flag.Parse()
 
+	if *executePath != "" {
+		os.Exit(runHeadless(*executePath, *outPath, *htmlPath))
+	}
+
 	// Determine the mode
 	useDarkMode := *darkMode || !*lightMode
 
 	// Create and run the app
 	app := NewApp(useDarkMode)
+	if *openPath != "" {
+		if err := app.loadNotebook(*openPath); err != nil {
+			fmt.Printf("Error opening %s: %v\n", *openPath, err)
+			os.Exit(1)
+		}
+	}
+	if *savePath != "" {
+		app.notebookPath = *savePath
+	}
 	if err := app.Run(); err != nil {
 		fmt.Printf("Error running application: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,140 @@
+package notebook
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ansiColorNames names the 8 base SGR colors in code order (30-37 / 40-47),
+// used only to label the generated CSS; ansiToHTML itself works on indices.
+var ansiColorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+var ansiCSS = buildAnsiCSS()
+
+func buildAnsiCSS() string {
+	var b strings.Builder
+	b.WriteString("body { font-family: monospace; background: #111; color: #ddd; }\n")
+	b.WriteString(".cell { margin-bottom: 1em; }\n")
+	b.WriteString(".code { color: #9cdcfe; }\n")
+	b.WriteString(".ansi-bold { font-weight: bold; }\n")
+	palette := []string{"#000", "#c00", "#0a0", "#aa0", "#00c", "#a0a", "#0aa", "#aaa"}
+	for i, name := range ansiColorNames {
+		fmt.Fprintf(&b, ".ansi-fg-%d { color: %s; } /* %s */\n", i, palette[i], name)
+		fmt.Fprintf(&b, ".ansi-bg-%d { background: %s; }\n", i, palette[i])
+	}
+	return b.String()
+}
+
+// ExportHTML renders the notebook as a standalone HTML document. Cell
+// content is shown verbatim; cell results are scanned for SGR escapes and
+// converted into <span class="ansi-..."> elements for bold and the 8 base
+// colors (SGR 30-37 / 40-47), so colored command output (e.g. `ls --color`)
+// survives the export instead of showing raw escape codes. Bright
+// (90-97 / 100-107), 256-color and truecolor codes are ignored and leave
+// the color in effect unchanged.
+func ExportHTML(nb *Notebook) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<style>\n")
+	b.WriteString(ansiCSS)
+	b.WriteString("</style>\n</head>\n<body>\n")
+
+	for _, cell := range nb.Cells {
+		lang := cell.Language
+		if lang == "" {
+			lang = "bash"
+		}
+		fmt.Fprintf(&b, "<div class=\"cell\">\n<pre class=\"code language-%s\">%s</pre>\n",
+			html.EscapeString(lang), html.EscapeString(cell.Content))
+		if cell.Result != "" {
+			b.WriteString("<pre class=\"result\">")
+			b.WriteString(ansiToHTML(cell.Result))
+			b.WriteString("</pre>\n")
+		}
+		b.WriteString("</div>\n")
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// ansiToHTML converts a string containing SGR color escapes into HTML,
+// tracking foreground, background and bold state across the whole string
+// and opening/closing <span> elements as that state changes.
+func ansiToHTML(s string) string {
+	var b strings.Builder
+	fg, bg := -1, -1
+	bold := false
+	open := false
+
+	flush := func() {
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		var classes []string
+		if bold {
+			classes = append(classes, "ansi-bold")
+		}
+		if fg >= 0 && fg < 8 {
+			classes = append(classes, fmt.Sprintf("ansi-fg-%d", fg))
+		}
+		if bg >= 0 && bg < 8 {
+			classes = append(classes, fmt.Sprintf("ansi-bg-%d", bg))
+		}
+		if len(classes) > 0 {
+			fmt.Fprintf(&b, "<span class=\"%s\">", strings.Join(classes, " "))
+			open = true
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '[' {
+			j := i + 2
+			for j < len(s) && s[j] != 'm' {
+				j++
+			}
+			if j < len(s) {
+				params := strings.Split(s[i+2:j], ";")
+				for k := 0; k < len(params); k++ {
+					p := params[k]
+					n, _ := strconv.Atoi(p)
+					switch {
+					case p == "", n == 0:
+						fg, bg, bold = -1, -1, false
+					case n == 38, n == 48:
+						// Skip the 5;n or 2;r;g;b arguments of an extended
+						// color so they aren't read as codes of their own.
+						if k+1 < len(params) && params[k+1] == "5" {
+							k += 2
+						} else if k+1 < len(params) && params[k+1] == "2" {
+							k += 4
+						}
+					case n == 1:
+						bold = true
+					case n >= 30 && n <= 37:
+						fg = n - 30
+					case n == 39:
+						fg = -1
+					case n >= 40 && n <= 47:
+						bg = n - 40
+					case n == 49:
+						bg = -1
+					}
+				}
+				flush()
+				i = j + 1
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(html.EscapeString(string(r)))
+		i += size
+	}
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
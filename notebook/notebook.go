@@ -0,0 +1,143 @@
+// Package notebook implements the on-disk notebook file format: Markdown
+// with one fenced code block per cell (the info string names the cell's
+// language) optionally followed by a fenced `output` block holding that
+// cell's last result. The format is intentionally plain Markdown so a
+// saved notebook renders sensibly on GitHub and diffs cleanly in git.
+package notebook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cell is one unit of the notebook: a language, the code that was run,
+// and (once executed) the output it produced.
+type Cell struct {
+	Language string
+	Content  string
+	Result   string
+}
+
+// Notebook is an ordered sequence of cells.
+type Notebook struct {
+	Cells []Cell
+}
+
+// Parse reads a notebook from its Markdown representation. Text outside
+// fenced code blocks (headings, prose) is ignored, so a notebook can be
+// annotated by hand without confusing round-tripping.
+func Parse(data []byte) (*Notebook, error) {
+	lines := strings.Split(string(data), "\n")
+	nb := &Notebook{}
+	var pending *Cell
+
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+		n := leadingBackticks(trimmed)
+		if n < 3 {
+			i++
+			continue
+		}
+
+		fence := trimmed[:n]
+		info := strings.TrimSpace(trimmed[n:])
+		body, next, err := readFence(lines, i+1, fence)
+		if err != nil {
+			return nil, err
+		}
+
+		if info == "output" {
+			if pending == nil {
+				return nil, fmt.Errorf("notebook: output block with no preceding cell (line %d)", i+1)
+			}
+			pending.Result = body
+			nb.Cells = append(nb.Cells, *pending)
+			pending = nil
+		} else {
+			if pending != nil {
+				nb.Cells = append(nb.Cells, *pending)
+			}
+			pending = &Cell{Language: info, Content: body}
+		}
+		i = next
+	}
+
+	if pending != nil {
+		nb.Cells = append(nb.Cells, *pending)
+	}
+	return nb, nil
+}
+
+// leadingBackticks returns the length of line's leading run of backticks.
+func leadingBackticks(line string) int {
+	n := 0
+	for n < len(line) && line[n] == '`' {
+		n++
+	}
+	return n
+}
+
+// readFence collects lines from start up to (not including) the closing
+// fence — a line of nothing but backticks at least as long as the opening
+// fence, the CommonMark rule for matching a fence's close — and returns the
+// body plus the index just past that closing fence.
+func readFence(lines []string, start int, fence string) (string, int, error) {
+	var b strings.Builder
+	for i := start; i < len(lines); i++ {
+		t := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(t, fence) && leadingBackticks(t) == len(t) {
+			return strings.TrimSuffix(b.String(), "\n"), i + 1, nil
+		}
+		b.WriteString(lines[i])
+		b.WriteString("\n")
+	}
+	return "", 0, fmt.Errorf("notebook: unterminated fenced block starting at line %d", start)
+}
+
+// fenceFor returns a backtick fence long enough that it can't be closed
+// early by a run of backticks already present in body, the same longer-fence
+// escaping CommonMark itself uses for code blocks containing backticks.
+func fenceFor(body string) string {
+	longest, run := 0, 0
+	for _, r := range body {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	n := longest + 1
+	if n < 3 {
+		n = 3
+	}
+	return strings.Repeat("`", n)
+}
+
+// String serializes the notebook back to its Markdown form.
+func (nb *Notebook) String() string {
+	var b strings.Builder
+	for i, cell := range nb.Cells {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		lang := cell.Language
+		if lang == "" {
+			lang = "bash"
+		}
+		fence := fenceFor(cell.Content)
+		fmt.Fprintf(&b, "%s%s\n%s\n%s\n", fence, lang, cell.Content, fence)
+		if cell.Result != "" {
+			resultFence := fenceFor(cell.Result)
+			fmt.Fprintf(&b, "\n%soutput\n%s\n%s\n", resultFence, cell.Result, resultFence)
+		}
+	}
+	return b.String()
+}
+
+// Bytes is a convenience wrapper around String for os.WriteFile callers.
+func (nb *Notebook) Bytes() []byte {
+	return []byte(nb.String())
+}
@@ -0,0 +1,99 @@
+package notebook
+
+import "testing"
+
+// roundTrip serializes nb and re-parses it, returning the result.
+func roundTrip(t *testing.T, nb *Notebook) *Notebook {
+	t.Helper()
+	got, err := Parse(nb.Bytes())
+	if err != nil {
+		t.Fatalf("Parse(nb.Bytes()): %v", err)
+	}
+	return got
+}
+
+func assertCells(t *testing.T, got *Notebook, want []Cell) {
+	t.Helper()
+	if len(got.Cells) != len(want) {
+		t.Fatalf("got %d cells, want %d: %+v", len(got.Cells), len(want), got.Cells)
+	}
+	for i := range want {
+		if got.Cells[i] != want[i] {
+			t.Errorf("cell %d = %+v, want %+v", i, got.Cells[i], want[i])
+		}
+	}
+}
+
+func TestRoundTripPlainCells(t *testing.T) {
+	nb := &Notebook{Cells: []Cell{
+		{Language: "bash", Content: "echo hello", Result: "hello"},
+		{Language: "python", Content: "print(1)", Result: "1"},
+		{Language: "node", Content: "console.log(1)"},
+	}}
+	assertCells(t, roundTrip(t, nb), nb.Cells)
+}
+
+// TestRoundTripEmbeddedFence is a regression test: a cell whose own content
+// contains a bare ``` line (e.g. a triple-quoted Python string holding a
+// markdown example) must not be split into two cells on save/load, since a
+// fixed 3-backtick fence can't tell that line apart from its own closing
+// fence.
+func TestRoundTripEmbeddedFence(t *testing.T) {
+	content := "doc = '''\nExample:\n```\nprint(1)\n```\n'''\nprint(doc)"
+	nb := &Notebook{Cells: []Cell{
+		{Language: "python", Content: content},
+	}}
+	assertCells(t, roundTrip(t, nb), nb.Cells)
+}
+
+// TestRoundTripEmbeddedFenceInResult covers the same hazard in a cell's
+// Result, e.g. a cell whose output is itself a rendered notebook example.
+func TestRoundTripEmbeddedFenceInResult(t *testing.T) {
+	nb := &Notebook{Cells: []Cell{
+		{Language: "bash", Content: "cat example.md", Result: "```\nsome fenced example\n```"},
+	}}
+	assertCells(t, roundTrip(t, nb), nb.Cells)
+}
+
+// TestRoundTripLongerEmbeddedFence covers content that already contains a
+// longer run of backticks than the default fence, which must bump the
+// chosen fence length again rather than just matching it.
+func TestRoundTripLongerEmbeddedFence(t *testing.T) {
+	content := "doc = \"\"\"\n````\nnested fence\n````\n\"\"\""
+	nb := &Notebook{Cells: []Cell{
+		{Language: "python", Content: content},
+	}}
+	assertCells(t, roundTrip(t, nb), nb.Cells)
+}
+
+func TestFenceForPicksLengthLongerThanLongestRun(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{body: "no backticks here", want: "```"},
+		{body: "one run of ``` three", want: "````"},
+		{body: "a longer run of `````` six", want: "```````"},
+	}
+	for _, c := range cases {
+		if got := fenceFor(c.body); got != c.want {
+			t.Errorf("fenceFor(%q) = %q, want %q", c.body, got, c.want)
+		}
+	}
+}
+
+func TestParseIgnoresNonFencedText(t *testing.T) {
+	data := []byte("# My Notebook\n\nSome prose.\n\n```bash\necho hi\n```\n")
+	nb, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	assertCells(t, nb, []Cell{{Language: "bash", Content: "echo hi"}})
+}
+
+func TestParseUnterminatedFenceErrors(t *testing.T) {
+	_, err := Parse([]byte("```bash\necho hi\n"))
+	if err == nil {
+		t.Fatal("Parse succeeded on an unterminated fence, want an error")
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Pane is one independent view onto the shared []Cell list: its own
+// scroll position and highlighted cell, so a split layout can show two
+// different cells (or the same one) at once. A pinned pane keeps showing
+// whatever cell it was pinned to instead of following the cursor, which is
+// how a pane gets dedicated to watching a long-running cell's output (e.g.
+// a live pipeline preview) while another pane moves on to editing.
+type Pane struct {
+	view    *tview.TextView
+	cellIdx int
+	pinned  bool
+}
+
+// paneNode is one node of the binary split tree: a leaf holds a Pane, a
+// container holds two children laid out by a Flex in the given direction.
+type paneNode struct {
+	pane       *Pane
+	horizontal bool
+	proportion int
+	children   []*paneNode
+	parent     *paneNode
+}
+
+// newPane creates a TextView-backed pane focused on cellIdx, styled to
+// match the app's current color mode and wired up to the shared pane key
+// handling (cell navigation, cell editing, and Ctrl-W pane commands).
+func (a *App) newPane(cellIdx int) *Pane {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetChangedFunc(func() {
+			a.app.Draw()
+		})
+
+	if a.darkMode {
+		view.SetTextColor(tcell.ColorWhite).SetBackgroundColor(tcell.ColorBlack)
+	} else {
+		view.SetTextColor(tcell.ColorBlack).SetBackgroundColor(tcell.ColorWhite)
+	}
+
+	p := &Pane{view: view, cellIdx: cellIdx}
+	view.SetInputCapture(a.paneInputCapture(p))
+	return p
+}
+
+// initPanes sets up the initial single-pane layout the app starts with.
+func (a *App) initPanes() {
+	root := a.newPane(a.currentCell)
+	node := &paneNode{pane: root, proportion: 1}
+	a.root = node
+	a.paneNodes = map[*Pane]*paneNode{root: node}
+	a.panes = []*Pane{root}
+	a.focused = root
+}
+
+// paneInputCapture builds the key handler shared by every pane's view. It
+// tracks which pane last received a key as the focused one, handles the
+// normal single-pane keybindings, and implements the two-key "Ctrl-W
+// <cmd>" pane commands (split, move focus, resize, pin).
+func (a *App) paneInputCapture(p *Pane) func(event *tcell.EventKey) *tcell.EventKey {
+	return func(event *tcell.EventKey) *tcell.EventKey {
+		a.focused = p
+		a.currentCell = p.cellIdx
+
+		if a.paneCmdPending {
+			a.paneCmdPending = false
+			if event.Key() == tcell.KeyRune {
+				switch event.Rune() {
+				case 's':
+					a.splitPane(true)
+				case 'v':
+					a.splitPane(false)
+				case 'h', 'k':
+					a.moveFocus(-1)
+				case 'j', 'l':
+					a.moveFocus(1)
+				case '+':
+					a.resizePane(1)
+				case '-':
+					a.resizePane(-1)
+				case 'p':
+					a.togglePin()
+				}
+			}
+			return nil
+		}
+
+		switch event.Key() {
+		case tcell.KeyCtrlW:
+			a.paneCmdPending = true
+			return nil
+		case tcell.KeyUp:
+			a.moveUp()
+		case tcell.KeyDown:
+			a.moveDown()
+		case tcell.KeyEnter:
+			a.executeCurrentCell(true)
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			a.removeCurrentCell()
+		case tcell.KeyCtrlS:
+			a.saveField.SetText(a.notebookPath)
+			a.app.SetRoot(a.saveField, true)
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case ' ':
+				a.openEditor()
+			case 'v':
+				a.editWithVim()
+			case '+':
+				a.copyCurrentCell()
+			case ':':
+				a.app.SetRoot(a.commandField, true)
+			case '|':
+				a.startPipePreview()
+			}
+		}
+		return event
+	}
+}
+
+// splitPane turns the focused pane into two: the pane it was showing stays
+// in one half, and a new pane (starting on the same cell) takes the other.
+// horizontal selects Ctrl-W s (stacked rows) vs Ctrl-W v (side-by-side
+// columns), matching vim/tmux's split-direction convention.
+func (a *App) splitPane(horizontal bool) {
+	node := a.paneNodes[a.focused]
+	if node == nil {
+		return
+	}
+	oldPane := node.pane
+	newPane := a.newPane(oldPane.cellIdx)
+
+	leftLeaf := &paneNode{pane: oldPane, parent: node, proportion: 1}
+	rightLeaf := &paneNode{pane: newPane, parent: node, proportion: 1}
+
+	node.pane = nil
+	node.horizontal = horizontal
+	node.children = []*paneNode{leftLeaf, rightLeaf}
+
+	a.paneNodes[oldPane] = leftLeaf
+	a.paneNodes[newPane] = rightLeaf
+	a.panes = append(a.panes, newPane)
+	a.focused = newPane
+
+	a.updateView()
+	a.showLayout()
+}
+
+// leaves returns every pane's node in a stable left-to-right, top-to-bottom
+// order, used to linearize Ctrl-W focus movement across the split tree.
+func (a *App) leaves() []*paneNode {
+	var out []*paneNode
+	var walk func(n *paneNode)
+	walk = func(n *paneNode) {
+		if n.pane != nil {
+			out = append(out, n)
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(a.root)
+	return out
+}
+
+// moveFocus steps focus to the next (delta=1) or previous (delta=-1) pane
+// in leaf order. The split tree has no fixed 2D grid, so h/k and j/l are
+// both mapped to this one linear cycle rather than true spatial
+// navigation — an honest approximation, not four independent directions.
+func (a *App) moveFocus(delta int) {
+	leaves := a.leaves()
+	if len(leaves) <= 1 {
+		return
+	}
+	cur := a.paneNodes[a.focused]
+	idx := 0
+	for i, l := range leaves {
+		if l == cur {
+			idx = i
+			break
+		}
+	}
+	idx = (idx + delta + len(leaves)) % len(leaves)
+	a.focused = leaves[idx].pane
+	a.currentCell = a.focused.cellIdx
+	a.showLayout()
+}
+
+// resizePane grows or shrinks the focused pane's share of its immediate
+// parent split by one proportion unit.
+func (a *App) resizePane(delta int) {
+	node := a.paneNodes[a.focused]
+	if node == nil || node.parent == nil {
+		return
+	}
+	node.proportion += delta
+	if node.proportion < 1 {
+		node.proportion = 1
+	}
+	a.showLayout()
+}
+
+// togglePin pins or unpins the focused pane. A pinned pane keeps showing
+// its current cell regardless of where the cursor moves in other panes —
+// how a pane gets dedicated to a cell's (e.g. a live pipeline preview's)
+// output.
+func (a *App) togglePin() {
+	a.focused.pinned = !a.focused.pinned
+	a.updateView()
+}
+
+// syncCursor applies a.currentCell (the shared cursor position) to the
+// focused pane and to every other non-pinned pane, then re-renders.
+func (a *App) syncCursor() {
+	a.focused.cellIdx = a.currentCell
+	for _, p := range a.panes {
+		if p != a.focused && !p.pinned {
+			p.cellIdx = a.currentCell
+		}
+	}
+	a.updateView()
+}
+
+// buildPrimitive recursively turns the split tree rooted at n into fresh
+// tview primitives. Rebuilding from scratch on every topology or
+// proportion change is simpler than mutating live Flex objects in place
+// and cheap enough for the handful of panes a terminal can usefully show.
+func buildPrimitive(n *paneNode) tview.Primitive {
+	if n.pane != nil {
+		return n.pane.view
+	}
+	dir := tview.FlexColumn
+	if n.horizontal {
+		dir = tview.FlexRow
+	}
+	flex := tview.NewFlex().SetDirection(dir)
+	for _, c := range n.children {
+		flex.AddItem(buildPrimitive(c), 0, c.proportion, false)
+	}
+	return flex
+}
+
+// showLayout rebuilds the split tree's primitives and makes it the
+// application's root again, restoring focus to whichever pane was active.
+// Every overlay (editor, command/pipe/save fields, history search) returns
+// here when it's dismissed.
+func (a *App) showLayout() {
+	a.app.SetRoot(buildPrimitive(a.root), true)
+	a.app.SetFocus(a.focused.view)
+}
+
+// updateView re-renders every pane from the current cell list.
+func (a *App) updateView() {
+	for _, p := range a.panes {
+		a.renderPane(p)
+	}
+}
+
+// renderPane draws the full cell list into p.view and scrolls it so p's
+// own selected cell (p.cellIdx) is centered — the per-pane counterpart of
+// what used to be a single global textView render.
+func (a *App) renderPane(p *Pane) {
+	p.view.Clear()
+	totalLines := 0
+	selectedCellStart := 0
+	for i, cell := range a.cells {
+		if i == p.cellIdx {
+			selectedCellStart = totalLines
+		}
+		header := fmt.Sprintf("[%d]", i+1)
+		if cell.status != "" {
+			header += fmt.Sprintf(" (%s)", cell.status)
+		}
+		fmt.Fprintf(p.view, "[\"cell-%d\"]%s:[\"\"]\n", i+1, header)
+		totalLines++
+		contentLines := strings.Count(cell.content, "\n") + 1
+		resultLines := strings.Count(cell.result, "\n") + 1
+		language, body := detectLanguage(cell.content)
+		fmt.Fprintf(p.view, "%s", highlightCode(language, body))
+		totalLines += contentLines
+		if cell.result != "" {
+			// cell.result may carry raw SGR escapes (aprocess.Execute keeps a
+			// command's own colors); translate them into tview's color tags
+			// so they render instead of showing up as literal escape codes.
+			fmt.Fprintf(p.view, "\n\n%s", tview.TranslateANSI(cell.result))
+			totalLines += resultLines + 2
+		}
+		fmt.Fprintf(p.view, "\n\n")
+		totalLines += 2
+	}
+	p.view.Highlight(fmt.Sprintf("cell-%d", p.cellIdx+1))
+
+	_, _, _, viewHeight := p.view.GetInnerRect()
+	scrollPosition := selectedCellStart - viewHeight/2
+	if scrollPosition < 0 {
+		scrollPosition = 0
+	}
+	p.view.ScrollTo(scrollPosition, 0)
+}